@@ -0,0 +1,82 @@
+// Package bus decouples the poller from webhook delivery (and any other
+// consumer of issue/PR activity) behind a small publish/subscribe API.
+// Publishing an Event never blocks on a slow or failing subscriber, so a
+// downstream outage can no longer stall the polling loop. A Bus backed
+// by a PersistentQueue additionally survives restarts: anything not yet
+// Ack'd is redelivered on the next Pop.
+package bus
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// Bus fans Events out to any number of live subscriber channels and,
+// when constructed with a queue, durably records every Event for
+// subscribers that need guaranteed (rather than best-effort) delivery.
+type Bus struct {
+	mu    sync.Mutex
+	subs  map[chan *Event]struct{}
+	queue *PersistentQueue
+}
+
+// New returns a Bus. queue may be nil if no subscriber needs durable,
+// replayable delivery.
+func New(queue *PersistentQueue) *Bus {
+	return &Bus{
+		subs:  make(map[chan *Event]struct{}),
+		queue: queue,
+	}
+}
+
+// Queue returns the PersistentQueue backing this Bus, or nil.
+func (b *Bus) Queue() *PersistentQueue {
+	return b.queue
+}
+
+// Publish records evt on the durable queue (if any) and offers it to
+// every live subscriber channel. A subscriber that isn't ready to
+// receive has the event dropped for it rather than blocking the
+// publisher; the durable queue is the delivery guarantee, channel
+// subscribers are best-effort.
+func (b *Bus) Publish(evt *Event) error {
+	if b.queue != nil {
+		if err := b.queue.Enqueue(evt); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- evt:
+		default:
+			slog.Warn("dropping event for slow subscriber", "owner", evt.Owner, "repo", evt.Repo, "kind", evt.Kind)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers ch to receive future Events. ch is automatically
+// unsubscribed when ctx is done.
+func (b *Bus) Subscribe(ctx context.Context, ch chan *Event) {
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.Unsubscribe(ch)
+	}()
+}
+
+// Unsubscribe stops ch from receiving further Events.
+func (b *Bus) Unsubscribe(ch chan *Event) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
@@ -0,0 +1,12 @@
+package bus
+
+import "hooksim/types"
+
+// Event is what the poller publishes and webhook delivery subscribes
+// to: a types.Event (the raw GitHub issue activity) plus the Owner/Repo
+// it happened on.
+type Event struct {
+	Owner string
+	Repo  string
+	types.Event
+}
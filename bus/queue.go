@@ -0,0 +1,136 @@
+package bus
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+var eventsBucket = []byte("events")
+
+// QueuedEvent pairs an Event with the sequence number PersistentQueue
+// assigned it, so a consumer can Ack it once delivery succeeds.
+type QueuedEvent struct {
+	ID    uint64
+	Event *Event
+}
+
+// PersistentQueue is a durable FIFO of Events backed by a bbolt bucket.
+// Enqueue never blocks; Pop waits (honoring ctx) until an event is
+// available. An event stays in the bucket, and will be handed out again
+// by Pop, until the consumer calls Ack — so a crash between Pop and Ack
+// simply means the event gets redelivered after restart.
+type PersistentQueue struct {
+	db       *bbolt.DB
+	notifyCh chan struct{}
+}
+
+// NewPersistentQueue opens (creating if necessary) the bbolt database at
+// path and ensures the events bucket exists.
+func NewPersistentQueue(path string) (*PersistentQueue, error) {
+	db, err := bbolt.Open(path, 0644, nil)
+	if err != nil {
+		return nil, fmt.Errorf("bus: opening queue db %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(eventsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("bus: creating events bucket: %v", err)
+	}
+
+	return &PersistentQueue{db: db, notifyCh: make(chan struct{}, 1)}, nil
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// Enqueue durably appends evt to the tail of the queue.
+func (q *PersistentQueue) Enqueue(evt *Event) error {
+	v, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("bus: marshalling event: %v", err)
+	}
+
+	err = q.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(itob(seq), v)
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q.notifyCh <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// peek returns the oldest un-acked event, or nil if the queue is empty.
+func (q *PersistentQueue) peek() (*QueuedEvent, error) {
+	var qe *QueuedEvent
+
+	err := q.db.View(func(tx *bbolt.Tx) error {
+		k, v := tx.Bucket(eventsBucket).Cursor().First()
+		if k == nil {
+			return nil
+		}
+
+		var evt Event
+		if err := json.Unmarshal(v, &evt); err != nil {
+			return err
+		}
+
+		qe = &QueuedEvent{ID: binary.BigEndian.Uint64(k), Event: &evt}
+		return nil
+	})
+
+	return qe, err
+}
+
+// Pop blocks until an event is available or ctx is done.
+func (q *PersistentQueue) Pop(ctx context.Context) (*QueuedEvent, error) {
+	for {
+		qe, err := q.peek()
+		if err != nil {
+			return nil, err
+		}
+		if qe != nil {
+			return qe, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-q.notifyCh:
+		}
+	}
+}
+
+// Ack removes the event with the given ID from the queue, confirming it
+// was delivered.
+func (q *PersistentQueue) Ack(id uint64) error {
+	return q.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(eventsBucket).Delete(itob(id))
+	})
+}
+
+// Close releases the underlying bbolt database file.
+func (q *PersistentQueue) Close() error {
+	return q.db.Close()
+}
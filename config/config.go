@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
 )
@@ -11,12 +12,30 @@ const (
 
 var (
 	DataDir = "./data"
+
+	// LogLevel is one of "debug", "info", "warn" or "error".
+	LogLevel = "info"
+
+	// LogFormat is one of "text" or "json".
+	LogFormat = "text"
+
+	// LogHTTP opts into logging each inbound /hook request and each
+	// outbound delivery attempt (method, URL, status, duration).
+	LogHTTP = false
 )
 
 type Account struct {
 	User  string
 	Token string
 	Hooks []Hook
+
+	// IncomingSecret is the secret GitHub signs this account's inbound
+	// webhook deliveries with. When set, handleHook verifies
+	// X-Hub-Signature(-256) against it before proxying anywhere, and
+	// rejects mismatches with 401. When empty, inbound requests for
+	// this account are proxied unverified, for backwards compatibility
+	// with configs predating this check.
+	IncomingSecret string
 }
 
 type Hook struct {
@@ -26,10 +45,41 @@ type Hook struct {
 	Secret string
 }
 
-var Accounts []Account
+// StorageConfig selects and configures the backend used to persist
+// per-repo LastAccess state. See the storage package for the available
+// backends.
+type StorageConfig struct {
+	// Type is one of "file" (default), "bolt" or "redis".
+	Type string
+
+	// Path is the data directory for the "file" backend (defaults to
+	// DataDir) or the database file path for the "bolt" backend.
+	Path string
+
+	// RedisAddr and RedisDB configure the "redis" backend.
+	RedisAddr string
+	RedisDB   int
+}
+
+var (
+	Accounts []Account
+	Storage  StorageConfig
+)
+
+// fileConfig mirrors the newer, wrapped on-disk JSON shape: an "Accounts"
+// array alongside top-level blocks like "Storage".
+type fileConfig struct {
+	Accounts []Account
+	Storage  StorageConfig
+}
 
 // Load takes the path to config file, read it and fill-in according to the
-// config file config.Accounts
+// config file config.Accounts and config.Storage.
+//
+// The config file is accepted in either of two shapes: the original bare
+// JSON array of Accounts, or the newer object wrapping Accounts alongside
+// Storage. This keeps every config.json written before Storage existed
+// loading unchanged.
 func Load(path string) error {
 	confInJSON, err := ioutil.ReadFile(path)
 
@@ -37,9 +87,17 @@ func Load(path string) error {
 		return err
 	}
 
-	err = json.Unmarshal(confInJSON, &Accounts)
-	if err != nil {
-		return err
+	if bytes.HasPrefix(bytes.TrimSpace(confInJSON), []byte("[")) {
+		if err := json.Unmarshal(confInJSON, &Accounts); err != nil {
+			return err
+		}
+	} else {
+		var fc fileConfig
+		if err := json.Unmarshal(confInJSON, &fc); err != nil {
+			return err
+		}
+		Accounts = fc.Accounts
+		Storage = fc.Storage
 	}
 
 	for i, acct := range Accounts {
@@ -2,13 +2,16 @@ package main
 
 import (
 	"flag"
-	"fmt"
+	"hooksim/bus"
 	"hooksim/config"
 	"hooksim/poller"
 	"hooksim/webhook"
-	"log"
+	"hooksim/webhook/delivery"
+	"log/slog"
 	"os"
 	"os/signal"
+	"path"
+	"strings"
 	"time"
 )
 
@@ -17,29 +20,83 @@ func parseFlag() (int, int, string) {
 	interval := flag.Int("i", 5, "Polling interval for all repositories")
 	conf := flag.String("c", "config.json", "Path to config file")
 	dataDir := flag.String("d", "./data", "Path to data directory")
-	verbose := flag.Bool("v", false, "Verbose")
+	logLevel := flag.String("log-level", "info", "Log level: debug|info|warn|error")
+	logFormat := flag.String("log-format", "text", "Log format: text|json")
+	logHTTP := flag.Bool("log-http", false, "Log each inbound /hook request and outbound delivery")
 	flag.Parse()
 
 	config.DataDir = *dataDir
-	config.Verbose = *verbose
+	config.LogLevel = *logLevel
+	config.LogFormat = *logFormat
+	config.LogHTTP = *logHTTP
 
 	return *port, *interval, *conf
 }
 
+// setupLogging points slog's default logger at a handler built from
+// config.LogLevel/config.LogFormat, so every package's bare slog.Info/
+// Debug/Warn/Error calls pick it up.
+func setupLogging() {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(config.LogLevel)}
+
+	var handler slog.Handler
+	if config.LogFormat == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
 func main() {
 
 	port, interval, conf := parseFlag()
+	setupLogging()
 
 	err := config.Load(conf)
 	if err != nil {
-		log.Printf("Error:%v\n", err)
+		slog.Error("error loading config", "err", err)
 		return
 	}
 
+	queue, err := bus.NewPersistentQueue(path.Join(config.DataDir, "bus.db"))
+	if err != nil {
+		slog.Error("error opening event queue", "err", err)
+		return
+	}
+	eventBus := bus.New(queue)
+
+	deliveryJournal, err := delivery.NewJournal(path.Join(config.DataDir, "delivery.db"))
+	if err != nil {
+		slog.Error("error opening delivery journal", "err", err)
+		return
+	}
+	webhook.DeliveryManager = delivery.NewManager(deliveryJournal, delivery.Config{})
+
 	server := webhook.Server(port)
-	p := poller.New(interval)
+	p := poller.New(interval, eventBus)
+	if p == nil {
+		return
+	}
+	subscriber := webhook.NewSubscriber(queue, webhook.DeliveryManager)
 
 	go p.Run()
+	go subscriber.Run()
+	go webhook.DeliveryManager.Run()
 	go server.ListenAndServe()
 
 	signalCh := make(chan os.Signal)
@@ -49,7 +106,7 @@ func main() {
 		sig := <-signalCh
 		switch sig.String() {
 		case "interrupt":
-			fmt.Printf("shutting down...\n")
+			slog.Info("shutting down...")
 
 			webhookStopCh := server.StopChan()
 			server.Stop(5 * time.Second)
@@ -58,7 +115,20 @@ func main() {
 			p.Stop()
 			<-p.StopDoneCh
 
-			fmt.Printf("done\n")
+			subscriber.Stop()
+			<-subscriber.StopDoneCh
+
+			webhook.DeliveryManager.Stop()
+			<-webhook.DeliveryManager.StopDoneCh
+
+			if err := queue.Close(); err != nil {
+				slog.Error("error closing event queue", "err", err)
+			}
+			if err := deliveryJournal.Close(); err != nil {
+				slog.Error("error closing delivery journal", "err", err)
+			}
+
+			slog.Info("done")
 			return
 		}
 	}
@@ -1,28 +1,44 @@
 package poller
 
 import (
+	"hooksim/bus"
 	"hooksim/config"
 	pollerWorker "hooksim/poller/worker"
-	"hooksim/webhook"
+	"hooksim/ratelimit"
+	"hooksim/storage"
+	"log/slog"
 	"time"
 
 	"golang.org/x/oauth2"
 )
 
 type Poller struct {
-	Workers  []*pollerWorker.Worker
-	Interval time.Duration
+	Workers   []*pollerWorker.Worker
+	Interval  time.Duration
+	Store     storage.Storage
+	Bus       *bus.Bus
+	RateLimit *ratelimit.Tracker
 
 	StopReqCh  chan bool
 	StopDoneCh chan bool
 }
 
-// New takes the polling interval in second and return a pointer to Poller
-// Poller contains several workers to do the actual repo polling jobs, number of worker
-// is same as number of repo being specified in the config file.
-func New(interval int) *Poller {
+// New takes the polling interval in second and the Bus to publish issue/PR
+// activity onto, and returns a pointer to Poller. Poller contains several
+// workers to do the actual repo polling jobs, number of worker is same as
+// number of repo being specified in the config file.
+func New(interval int, b *bus.Bus) *Poller {
+	store, err := storage.New(config.Storage)
+	if err != nil {
+		slog.Error("error initializing storage backend", "err", err)
+		return nil
+	}
+
 	poller := &Poller{
 		Interval:   time.Duration(interval) * time.Second,
+		Store:      store,
+		Bus:        b,
+		RateLimit:  ratelimit.NewTracker(),
 		StopReqCh:  make(chan bool),
 		StopDoneCh: make(chan bool),
 	}
@@ -30,7 +46,7 @@ func New(interval int) *Poller {
 	for _, acct := range config.Accounts {
 		client := oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: acct.Token}))
 		for _, hook := range acct.Hooks {
-			poller.Workers = append(poller.Workers, pollerWorker.New(acct.User, hook.Repo, client))
+			poller.Workers = append(poller.Workers, pollerWorker.New(acct.User, hook.Repo, acct.Token, client, store, poller.RateLimit))
 		}
 	}
 
@@ -44,20 +60,30 @@ func (poller *Poller) Stop() {
 
 // Run will start the poller task, this call will block until Stop() is called
 func (poller *Poller) Run() {
-	delay := poller.Interval / time.Duration(len(poller.Workers))
+	userInterval := poller.Interval / time.Duration(len(poller.Workers))
 
 	defer func() {
+		if err := poller.Store.Close(); err != nil {
+			slog.Error("error closing storage backend", "err", err)
+		}
 		poller.StopDoneCh <- true
 	}()
 
 	for {
 		for _, worker := range poller.Workers {
+			delay := poller.RateLimit.Delay(worker.Token, userInterval)
+			budget := poller.RateLimit.Get(worker.Token)
+			slog.Debug("scheduling poll", "owner", worker.Owner, "repo", worker.Repo, "delay", delay, "remaining", budget.Remaining, "reset", budget.Reset)
+
 			select {
 			case <-poller.StopReqCh:
 				return
 			case <-time.After(delay):
-				if issueActorPairs := worker.PollRepo(); len(issueActorPairs) > 0 {
-					webhook.TriggerIssueRenamedWebHook(worker.Owner, worker.Repo, issueActorPairs, worker.Client)
+				for _, e := range worker.PollRepo() {
+					evt := &bus.Event{Owner: worker.Owner, Repo: worker.Repo, Event: e}
+					if err := poller.Bus.Publish(evt); err != nil {
+						slog.Error("error publishing event", "owner", worker.Owner, "repo", worker.Repo, "kind", e.Kind, "err", err)
+					}
 				}
 			}
 		}
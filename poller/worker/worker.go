@@ -1,41 +1,44 @@
 package worker
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"hooksim/config"
+	"hooksim/ratelimit"
+	"hooksim/storage"
 	"hooksim/types"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
-	"path"
 	"strconv"
 	"strings"
 )
 
-type LastAccess struct {
-	ETag    string
-	EventID uint64
-}
+type LastAccess = types.LastAccess
 
 type Worker struct {
 	Client     *http.Client
 	LastAccess LastAccess
 	Owner      string
 	Repo       string
+	Token      string
+	Store      storage.Storage
+	RateLimit  *ratelimit.Tracker
 }
 
-// New takes the repo owner,repo name, and the http client with oauth2 header set up
-// to creates a new Worker and return the pointer to it.
-// It will also resotre the last access info (ETag and last issue event ID) from local storage
-func New(owner, repo string, client *http.Client) *Worker {
+// New takes the repo owner,repo name, the OAuth token and http client set up
+// with it, the Storage used to persist poll state, and the Tracker shared
+// rate limit budgets are recorded against, and creates a new Worker.
+// It will also restore the last access info (ETag and last issue event ID) from store.
+func New(owner, repo, token string, client *http.Client, store storage.Storage, rateLimit *ratelimit.Tracker) *Worker {
 	w := &Worker{
-		Owner:  owner,
-		Repo:   repo,
-		Client: client,
+		Owner:     owner,
+		Repo:      repo,
+		Token:     token,
+		Client:    client,
+		Store:     store,
+		RateLimit: rateLimit,
 	}
 	w.loadLastAccess()
 	return w
@@ -54,28 +57,45 @@ func (worker *Worker) getIssueEvent(page int, useETag bool) (*http.Response, err
 
 	resp, err := worker.Client.Do(req)
 	if err != nil {
-		log.Printf("Error in get issue events:%v\n, err")
+		slog.Error("error getting issue events", "owner", worker.Owner, "repo", worker.Repo, "err", err)
 		return nil, err
 	}
 
+	worker.RateLimit.Update(worker.Token, resp)
+	budget := worker.RateLimit.Get(worker.Token)
+	slog.Debug("rate limit budget", "owner", worker.Owner, "repo", worker.Repo, "remaining", budget.Remaining, "reset", budget.Reset)
+
 	return resp, nil
 }
 
-// PollRepo makes query to /repos/:user/:repo/issues/events, scan for unread issue event
-// if there is unread "renamed" issue event, it will enqueue the correspond issue and actor
-// content pair to a queue, this queue will be output of this method. By exemine the length
-// of the queue, we can decide we should trigger webhook call or not.
+// supportedEventKinds is the set of issue event "event" values this
+// worker knows how to turn into a types.Event; anything else (e.g.
+// "subscribed", "mentioned") is skipped, same as the rest of GitHub's
+// issue events API that hooksim doesn't simulate webhooks for.
+var supportedEventKinds = map[types.EventKind]bool{
+	types.EventAssigned:     true,
+	types.EventUnassigned:   true,
+	types.EventLabeled:      true,
+	types.EventUnlabeled:    true,
+	types.EventClosed:       true,
+	types.EventReopened:     true,
+	types.EventMilestoned:   true,
+	types.EventDemilestoned: true,
+	types.EventRenamed:      true,
+}
+
+// PollRepo makes query to /repos/:user/:repo/issues/events, scans for unread issue events
+// of a kind hooksim knows how to turn into a webhook call, and returns them. By examining
+// the length of the returned slice, we can decide we should trigger webhook calls or not.
 //
 // GET query which with param "page=1" will have If-None-Match in the request header so as to
 // speed up the query and reduce the comsumption of github API quota
-func (worker *Worker) PollRepo() []types.IssueActorPair {
-	if config.Verbose {
-		fmt.Printf("polling %s/%s...\n", worker.Owner, worker.Repo)
-	}
+func (worker *Worker) PollRepo() []types.Event {
+	slog.Debug("polling repo", "owner", worker.Owner, "repo", worker.Repo)
 
 	resp, err := worker.getIssueEvent(1, true)
 	if err != nil {
-		log.Printf("Error in getting issue event: %v\n", err)
+		slog.Error("error getting issue event", "owner", worker.Owner, "repo", worker.Repo, "err", err)
 	}
 
 	if resp.StatusCode == 304 {
@@ -89,10 +109,10 @@ func (worker *Worker) PollRepo() []types.IssueActorPair {
 	etag := resp.Header.Get("ETag")
 	var latestID uint64
 	var maxPage int
-	var pairs []types.IssueActorPair
+	var events []types.Event
 
 	for {
-		foundLastAccess, latestIDInPage, pairsInPage, err := worker.parseResponse(resp)
+		foundLastAccess, latestIDInPage, eventsInPage, err := worker.parseResponse(resp)
 
 		if maxPage == 0 && resp.Header.Get("Link") != "" {
 			maxPage = getMaxPage(resp.Header.Get("Link"))
@@ -103,12 +123,12 @@ func (worker *Worker) PollRepo() []types.IssueActorPair {
 		}
 
 		if err != nil {
-			log.Printf("Error in parsing response: %v", err)
+			slog.Error("error parsing response", "owner", worker.Owner, "repo", worker.Repo, "err", err)
 			return nil
 		}
 
 		if foundLastAccess || worker.LastAccess.EventID == 0 {
-			pairs = append(pairs, pairsInPage...)
+			events = append(events, eventsInPage...)
 			break
 		} else {
 			if resp.Body != nil {
@@ -126,20 +146,18 @@ func (worker *Worker) PollRepo() []types.IssueActorPair {
 
 	worker.updateLastAccess(etag, latestID)
 
-	if config.Verbose {
-		if len(pairs) > 0 {
-			fmt.Printf("New rename event detected.\n")
-		}
+	if len(events) > 0 {
+		slog.Debug("new issue event(s) detected", "owner", worker.Owner, "repo", worker.Repo, "count", len(events))
 	}
 
-	return pairs
+	return events
 }
 
-// parseResponse is the helper function for PollRepo, it is used to scan for unread renamed issue
-// event and return the correspond issue and actor content pair
+// parseResponse is the helper function for PollRepo, it is used to scan for unread issue
+// events of a supported kind and return them as types.Event.
 // It will also indicated whether it is time to stop query the next page by comparing the event ID
 // with this stored one
-func (worker *Worker) parseResponse(resp *http.Response) (foundLastAccess bool, latestID uint64, pairs []types.IssueActorPair, err error) {
+func (worker *Worker) parseResponse(resp *http.Response) (foundLastAccess bool, latestID uint64, events []types.Event, err error) {
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return false, 0, nil, err
@@ -173,29 +191,35 @@ func (worker *Worker) parseResponse(resp *http.Response) (foundLastAccess bool,
 			break
 		}
 
-		if config.Verbose {
-			fmt.Printf("> curID:%v lastID:%v event:%v\n", curID, lastID, eventType)
-		}
-
-		if eventType == "renamed" {
-			pairs = append(pairs, types.IssueActorPair{Issue: []byte(event["issue"]), Actor: []byte(event["actor"])})
+		slog.Debug("issue event", "owner", worker.Owner, "repo", worker.Repo, "event_id", curID, "last_id", lastID, "kind", eventType)
+
+		kind := types.EventKind(eventType)
+		if supportedEventKinds[kind] {
+			events = append(events, types.Event{
+				Kind:      kind,
+				Issue:     []byte(event["issue"]),
+				Actor:     []byte(event["actor"]),
+				Label:     []byte(event["label"]),
+				Assignee:  []byte(event["assignee"]),
+				Milestone: []byte(event["milestone"]),
+			})
 		}
 
 	}
 
-	return foundLastAccess, latestID, pairs, nil
+	return foundLastAccess, latestID, events, nil
 }
 
 // getMaxPage parse the content of Link Header and extact the max page number
 func getMaxPage(link string) int {
 	lastPageURL, err := url.Parse(strings.Trim(strings.Split(strings.Split(link, ",")[1], ";")[0], " <>"))
 	if err != nil {
-		log.Printf("Error when parsing last page url: %v\n", err)
+		slog.Error("error parsing last page url", "err", err)
 		return 0
 	}
 	maxPage, err := strconv.Atoi(lastPageURL.Query().Get("page"))
 	if err != nil {
-		log.Printf("Error when parsing last page url: %v\n", err)
+		slog.Error("error parsing last page url", "err", err)
 		return 0
 	}
 
@@ -209,47 +233,19 @@ func (worker *Worker) updateLastAccess(etag string, eventID uint64) {
 	worker.saveLastAccess()
 }
 
-// loadLastAccess load the ETag and latest seen issue event ID from local storage
+// loadLastAccess loads the ETag and latest seen issue event ID from worker.Store
 func (worker *Worker) loadLastAccess() {
-	content, err := ioutil.ReadFile(path.Join(config.DataDir, worker.Owner, worker.Repo))
+	la, err := worker.Store.Load(worker.Owner, worker.Repo)
 	if err != nil {
+		slog.Error("error loading last access information", "owner", worker.Owner, "repo", worker.Repo, "err", err)
 		return
 	}
-
-	buf := bytes.NewBuffer(content)
-	etag, err := buf.ReadString('\n')
-	if err != nil {
-		return
-	}
-	etag = strings.Trim(etag, "\n ")
-
-	idStr, err := buf.ReadString('\n')
-	if err != nil {
-		return
-	}
-	idStr = strings.Trim(idStr, "\n ")
-	id, err := strconv.ParseUint(idStr, 10, 64)
-	if err != nil {
-		return
-	}
-
-	worker.LastAccess.ETag = etag
-	worker.LastAccess.EventID = id
+	worker.LastAccess = la
 }
 
-// saveLastAccess save the ETag and latest seen issue event ID to local storage
+// saveLastAccess saves the ETag and latest seen issue event ID to worker.Store
 func (worker *Worker) saveLastAccess() {
-	errFmt := "Error in storing last access infomation: %v\n"
-
-	err := os.MkdirAll(path.Join(config.DataDir, worker.Owner), 0755)
-	if err != nil {
-		log.Printf(errFmt, err)
-		return
-	}
-
-	content := fmt.Sprintf("%v\n%v\n", worker.LastAccess.ETag, worker.LastAccess.EventID)
-	err = ioutil.WriteFile(path.Join(config.DataDir, worker.Owner, worker.Repo), []byte(content), 0644)
-	if err != nil {
-		log.Printf(errFmt, err)
+	if err := worker.Store.Save(worker.Owner, worker.Repo, worker.LastAccess); err != nil {
+		slog.Error("error storing last access information", "owner", worker.Owner, "repo", worker.Repo, "err", err)
 	}
 }
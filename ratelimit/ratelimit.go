@@ -0,0 +1,120 @@
+// Package ratelimit tracks GitHub's per-token API rate limit budget, so
+// the poller can spread its polling across the remaining quota instead
+// of hammering the API on a fixed schedule and getting 403'd.
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Bucket is the most recently observed rate limit state for one OAuth
+// token, as reported by GitHub's X-RateLimit-* (and, for secondary
+// limits, Retry-After) response headers.
+type Bucket struct {
+	Remaining  int
+	Reset      time.Time
+	RetryAfter time.Time
+}
+
+// Tracker shares rate limit Buckets across every Worker polling with the
+// same token, so a single account's budget is tracked once no matter how
+// many repos it has hooks on.
+type Tracker struct {
+	mu      sync.RWMutex
+	buckets map[string]*Bucket
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{buckets: make(map[string]*Bucket)}
+}
+
+// Update records the rate limit headers from resp against token.
+func (t *Tracker) Update(token string, resp *http.Response) {
+	remaining, haveRemaining := parseInt(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, haveReset := parseInt(resp.Header.Get("X-RateLimit-Reset"))
+	retryAfter, haveRetryAfter := parseInt(resp.Header.Get("Retry-After"))
+
+	if !haveRemaining && !haveReset && !haveRetryAfter {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.buckets[token]
+	if !ok {
+		b = &Bucket{}
+		t.buckets[token] = b
+	}
+
+	if haveRemaining {
+		b.Remaining = remaining
+	}
+	if haveReset {
+		b.Reset = time.Unix(int64(reset), 0)
+	}
+	if haveRetryAfter {
+		b.RetryAfter = time.Now().Add(time.Duration(retryAfter) * time.Second)
+	} else {
+		b.RetryAfter = time.Time{}
+	}
+}
+
+// Get returns the current Bucket for token, or its zero value if
+// nothing has been observed for it yet.
+func (t *Tracker) Get(token string) Bucket {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if b, ok := t.buckets[token]; ok {
+		return *b
+	}
+	return Bucket{}
+}
+
+// Delay returns how long to wait before the next poll for token, never
+// shorter than userInterval: max(userInterval, timeUntilReset/remaining).
+// If the budget is exhausted, or a secondary rate limit's Retry-After
+// hasn't elapsed yet, it short-circuits to sleeping until then instead.
+func (t *Tracker) Delay(token string, userInterval time.Duration) time.Duration {
+	b := t.Get(token)
+
+	if !b.RetryAfter.IsZero() {
+		if d := time.Until(b.RetryAfter); d > userInterval {
+			return d
+		}
+	}
+
+	if b.Reset.IsZero() {
+		return userInterval
+	}
+
+	timeUntilReset := time.Until(b.Reset)
+	if timeUntilReset <= 0 {
+		return userInterval
+	}
+
+	if b.Remaining <= 0 {
+		return timeUntilReset
+	}
+
+	if perRequest := timeUntilReset / time.Duration(b.Remaining); perRequest > userInterval {
+		return perRequest
+	}
+	return userInterval
+}
+
+func parseInt(v string) (int, bool) {
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func respWithHeaders(remaining, reset, retryAfter int, setRemaining, setReset, setRetryAfter bool) *http.Response {
+	h := http.Header{}
+	if setRemaining {
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	}
+	if setReset {
+		h.Set("X-RateLimit-Reset", strconv.Itoa(reset))
+	}
+	if setRetryAfter {
+		h.Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	return &http.Response{Header: h}
+}
+
+func TestDelayWithNoDataFallsBackToUserInterval(t *testing.T) {
+	tr := NewTracker()
+	d := tr.Delay("token", time.Minute)
+	if d != time.Minute {
+		t.Errorf("Delay() = %v, want %v", d, time.Minute)
+	}
+}
+
+func TestDelayRetryAfterOverridesWhenInFuture(t *testing.T) {
+	tr := NewTracker()
+	tr.Update("token", respWithHeaders(100, int(time.Now().Add(time.Hour).Unix()), 120, true, true, true))
+
+	d := tr.Delay("token", time.Minute)
+	if d < 119*time.Second || d > 121*time.Second {
+		t.Errorf("Delay() = %v, want ~120s", d)
+	}
+}
+
+func TestDelayRetryAfterIgnoredWhenShorterThanUserInterval(t *testing.T) {
+	tr := NewTracker()
+	tr.Update("token", respWithHeaders(100, int(time.Now().Add(time.Hour).Unix()), 1, true, true, true))
+
+	d := tr.Delay("token", time.Minute)
+	if d < time.Minute {
+		t.Errorf("Delay() = %v, want at least %v (userInterval floor)", d, time.Minute)
+	}
+}
+
+func TestDelayResetInPastFallsBackToUserInterval(t *testing.T) {
+	tr := NewTracker()
+	tr.Update("token", respWithHeaders(100, int(time.Now().Add(-time.Hour).Unix()), 0, true, true, false))
+
+	d := tr.Delay("token", time.Minute)
+	if d != time.Minute {
+		t.Errorf("Delay() = %v, want %v", d, time.Minute)
+	}
+}
+
+func TestDelayRemainingExhaustedWaitsUntilReset(t *testing.T) {
+	tr := NewTracker()
+	tr.Update("token", respWithHeaders(0, int(time.Now().Add(10*time.Minute).Unix()), 0, true, true, false))
+
+	d := tr.Delay("token", time.Minute)
+	if d < 9*time.Minute || d > 11*time.Minute {
+		t.Errorf("Delay() = %v, want ~10m (time until reset)", d)
+	}
+}
+
+func TestDelaySpreadsAcrossRemainingBudget(t *testing.T) {
+	tr := NewTracker()
+	// 10 minutes until reset, 10 requests remaining => 1 minute per request,
+	// which is longer than a 1s userInterval, so Delay should return it.
+	tr.Update("token", respWithHeaders(10, int(time.Now().Add(10*time.Minute).Unix()), 0, true, true, false))
+
+	d := tr.Delay("token", time.Second)
+	if d < 55*time.Second || d > 65*time.Second {
+		t.Errorf("Delay() = %v, want ~1m (timeUntilReset/remaining)", d)
+	}
+}
+
+func TestDelayUserIntervalWinsWhenLarger(t *testing.T) {
+	tr := NewTracker()
+	// 10 minutes until reset, 1000 requests remaining => well under a second
+	// per request, so the userInterval floor should win instead.
+	tr.Update("token", respWithHeaders(1000, int(time.Now().Add(10*time.Minute).Unix()), 0, true, true, false))
+
+	d := tr.Delay("token", time.Minute)
+	if d != time.Minute {
+		t.Errorf("Delay() = %v, want %v (userInterval floor)", d, time.Minute)
+	}
+}
+
+func TestUpdateIgnoresResponseWithNoRateLimitHeaders(t *testing.T) {
+	tr := NewTracker()
+	tr.Update("token", respWithHeaders(0, 0, 0, false, false, false))
+
+	if b := tr.Get("token"); b != (Bucket{}) {
+		t.Errorf("Get() = %+v, want zero value", b)
+	}
+}
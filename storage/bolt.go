@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"hooksim/types"
+
+	"go.etcd.io/bbolt"
+)
+
+var lastAccessBucket = []byte("last_access")
+
+// BoltStorage keeps LastAccess state in a single embedded bbolt database
+// file, one key per "owner/repo". bbolt serializes all writers itself, so
+// no extra locking is needed here.
+type BoltStorage struct {
+	db *bbolt.DB
+}
+
+// NewBoltStorage opens (creating if necessary) the bbolt database at path
+// and ensures the last_access bucket exists.
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, 0644, nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage: opening bolt db %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(lastAccessBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("storage: creating bucket: %v", err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+func boltKey(owner, repo string) []byte {
+	return []byte(owner + "/" + repo)
+}
+
+func (bs *BoltStorage) Load(owner, repo string) (types.LastAccess, error) {
+	var la types.LastAccess
+
+	err := bs.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(lastAccessBucket).Get(boltKey(owner, repo))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, &la)
+	})
+
+	return la, err
+}
+
+func (bs *BoltStorage) Save(owner, repo string, la types.LastAccess) error {
+	v, err := json.Marshal(la)
+	if err != nil {
+		return fmt.Errorf("storage: marshalling last access: %v", err)
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(lastAccessBucket).Put(boltKey(owner, repo), v)
+	})
+}
+
+// Close releases the underlying bbolt database file.
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
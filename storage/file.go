@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"hooksim/types"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FileStorage is the original on-disk layout: a two-line text file
+// (ETag, then event ID) at <dir>/<owner>/<repo>. Saves go through a
+// write-to-temp-then-rename dance with fsync on both the temp file and
+// the containing directory, so a crash mid-write can never leave a
+// truncated or half-written LastAccess file behind.
+type FileStorage struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStorage returns a FileStorage rooted at dir, creating dir if
+// it does not already exist.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("storage: creating data dir %s: %v", dir, err)
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+func (fs *FileStorage) repoPath(owner, repo string) string {
+	return path.Join(fs.dir, owner, repo)
+}
+
+// Load returns the zero value and a nil error if owner/repo has never
+// been saved, matching the poller's historical "first ever poll" behaviour.
+func (fs *FileStorage) Load(owner, repo string) (types.LastAccess, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var la types.LastAccess
+
+	content, err := ioutil.ReadFile(fs.repoPath(owner, repo))
+	if os.IsNotExist(err) {
+		return la, nil
+	}
+	if err != nil {
+		return la, err
+	}
+
+	buf := bytes.NewBuffer(content)
+	etag, err := buf.ReadString('\n')
+	if err != nil {
+		return la, nil
+	}
+	la.ETag = strings.Trim(etag, "\n ")
+
+	idStr, err := buf.ReadString('\n')
+	if err != nil {
+		return la, nil
+	}
+	id, err := strconv.ParseUint(strings.Trim(idStr, "\n "), 10, 64)
+	if err != nil {
+		return la, nil
+	}
+	la.EventID = id
+
+	return la, nil
+}
+
+// Save atomically replaces the stored LastAccess for owner/repo: the new
+// content is written to a temp file in the same directory, fsync'd,
+// renamed over the target, and the directory entry itself is fsync'd so
+// the rename survives a crash.
+func (fs *FileStorage) Save(owner, repo string, la types.LastAccess) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	ownerDir := path.Join(fs.dir, owner)
+	if err := os.MkdirAll(ownerDir, 0755); err != nil {
+		return fmt.Errorf("storage: creating owner dir %s: %v", ownerDir, err)
+	}
+
+	tmp, err := ioutil.TempFile(ownerDir, "."+repo+".tmp")
+	if err != nil {
+		return fmt.Errorf("storage: creating temp file: %v", err)
+	}
+	tmpName := tmp.Name()
+
+	content := fmt.Sprintf("%v\n%v\n", la.ETag, la.EventID)
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("storage: writing temp file: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return fmt.Errorf("storage: fsyncing temp file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("storage: closing temp file: %v", err)
+	}
+
+	if err := os.Rename(tmpName, fs.repoPath(owner, repo)); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("storage: renaming temp file into place: %v", err)
+	}
+
+	if dirFile, err := os.Open(ownerDir); err == nil {
+		dirFile.Sync()
+		dirFile.Close()
+	}
+
+	return nil
+}
+
+// Close is a no-op for FileStorage; there is no handle to release.
+func (fs *FileStorage) Close() error {
+	return nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"fmt"
+	"hooksim/types"
+	"sync"
+	"testing"
+)
+
+func TestFileStorageSaveLoad(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	if la, err := fs.Load("owner", "repo"); err != nil || la != (types.LastAccess{}) {
+		t.Fatalf("Load of unseen repo = %+v, %v, want zero value, nil", la, err)
+	}
+
+	want := types.LastAccess{ETag: "abc123", EventID: 42}
+	if err := fs.Save("owner", "repo", want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := fs.Load("owner", "repo")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got != want {
+		t.Fatalf("Load after Save = %+v, want %+v", got, want)
+	}
+}
+
+// TestFileStorageConcurrentSaveLoad exercises many goroutines Save/Load-ing
+// distinct repos at once, guarding against the partial writes and races the
+// old hand-rolled file layout was prone to.
+func TestFileStorageConcurrentSaveLoad(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	const workers = 20
+	const iterations = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo := fmt.Sprintf("repo-%d", i)
+			for j := 0; j < iterations; j++ {
+				la := types.LastAccess{ETag: fmt.Sprintf("etag-%d", j), EventID: uint64(j)}
+				if err := fs.Save("owner", repo, la); err != nil {
+					t.Errorf("Save(%s): %v", repo, err)
+					return
+				}
+				if _, err := fs.Load("owner", repo); err != nil {
+					t.Errorf("Load(%s): %v", repo, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers; i++ {
+		repo := fmt.Sprintf("repo-%d", i)
+		la, err := fs.Load("owner", repo)
+		if err != nil {
+			t.Fatalf("final Load(%s): %v", repo, err)
+		}
+		if la.EventID != iterations-1 {
+			t.Fatalf("final Load(%s).EventID = %d, want %d", repo, la.EventID, iterations-1)
+		}
+	}
+}
+
+// TestFileStorageConcurrentSameKey exercises concurrent Saves to the same
+// owner/repo key, checking that Load never observes anything other than one
+// of the values actually written (no torn reads from a half-finished write).
+func TestFileStorageConcurrentSameKey(t *testing.T) {
+	fs, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStorage: %v", err)
+	}
+
+	const n = 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			la := types.LastAccess{ETag: fmt.Sprintf("etag-%d", i), EventID: uint64(i)}
+			if err := fs.Save("owner", "repo", la); err != nil {
+				t.Errorf("Save: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	la, err := fs.Load("owner", "repo")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if la.EventID >= n {
+		t.Fatalf("Load returned EventID %d, not one of the written values [0,%d)", la.EventID, n)
+	}
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"hooksim/config"
+	"hooksim/types"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// RedisStorage keeps LastAccess state in a Redis hash keyed by
+// "hooksim:last_access:<owner>/<repo>", so multiple hooksim instances can
+// share poll state behind a single Redis.
+type RedisStorage struct {
+	pool *redis.Pool
+}
+
+// NewRedisStorage dials cfg.RedisAddr using a small connection pool.
+func NewRedisStorage(cfg config.StorageConfig) (*RedisStorage, error) {
+	if cfg.RedisAddr == "" {
+		return nil, fmt.Errorf("storage: redis backend requires RedisAddr")
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     5,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			conn, err := redis.Dial("tcp", cfg.RedisAddr, redis.DialDatabase(cfg.RedisDB))
+			if err != nil {
+				return nil, err
+			}
+			return conn, nil
+		},
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PING"); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("storage: connecting to redis at %s: %v", cfg.RedisAddr, err)
+	}
+
+	return &RedisStorage{pool: pool}, nil
+}
+
+func redisKey(owner, repo string) string {
+	return fmt.Sprintf("hooksim:last_access:%s/%s", owner, repo)
+}
+
+func (rs *RedisStorage) Load(owner, repo string) (types.LastAccess, error) {
+	var la types.LastAccess
+
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	v, err := redis.Bytes(conn.Do("GET", redisKey(owner, repo)))
+	if err == redis.ErrNil {
+		return la, nil
+	}
+	if err != nil {
+		return la, err
+	}
+
+	if err := json.Unmarshal(v, &la); err != nil {
+		return la, fmt.Errorf("storage: unmarshalling last access: %v", err)
+	}
+	return la, nil
+}
+
+func (rs *RedisStorage) Save(owner, repo string, la types.LastAccess) error {
+	v, err := json.Marshal(la)
+	if err != nil {
+		return fmt.Errorf("storage: marshalling last access: %v", err)
+	}
+
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", redisKey(owner, repo), v)
+	return err
+}
+
+// Close shuts down the underlying connection pool.
+func (rs *RedisStorage) Close() error {
+	return rs.pool.Close()
+}
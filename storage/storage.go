@@ -0,0 +1,49 @@
+// Package storage persists the per-repo poller.Worker LastAccess state
+// (ETag + last issue event ID) so polling can resume across restarts.
+//
+// The on-disk text file layout used historically by the poller is fragile:
+// no atomic replace, no fsync, and no way to share state across machines.
+// Storage abstracts that away behind a small interface with a handful of
+// interchangeable backends selected via config.Storage.
+package storage
+
+import (
+	"fmt"
+	"hooksim/config"
+	"hooksim/types"
+	"path"
+)
+
+// Storage persists and retrieves LastAccess state for a repo, keyed by
+// owner/repo. Implementations must be safe for concurrent use, since the
+// poller runs one goroutine per worker against a single shared Storage.
+type Storage interface {
+	// Load returns the stored LastAccess for owner/repo. A repo that has
+	// never been saved returns the zero value and a nil error.
+	Load(owner, repo string) (types.LastAccess, error)
+	Save(owner, repo string, la types.LastAccess) error
+	Close() error
+}
+
+// New selects and initializes the Storage backend described by cfg.
+// An empty cfg.Type defaults to the "file" backend rooted at config.DataDir.
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch cfg.Type {
+	case "", "file":
+		dir := cfg.Path
+		if dir == "" {
+			dir = config.DataDir
+		}
+		return NewFileStorage(dir)
+	case "bolt":
+		dbPath := cfg.Path
+		if dbPath == "" {
+			dbPath = path.Join(config.DataDir, "hooksim.db")
+		}
+		return NewBoltStorage(dbPath)
+	case "redis":
+		return NewRedisStorage(cfg)
+	default:
+		return nil, fmt.Errorf("storage: unknown backend type %q", cfg.Type)
+	}
+}
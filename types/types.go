@@ -5,7 +5,40 @@ type URLSecretPair struct {
 	Secret string
 }
 
-type IssueActorPair struct {
+// EventKind is the GitHub issue event "event" field, e.g. "renamed" or
+// "assigned" - the same string GitHub uses as the webhook payload's
+// "action" for most of these.
+type EventKind string
+
+const (
+	EventAssigned     EventKind = "assigned"
+	EventUnassigned   EventKind = "unassigned"
+	EventLabeled      EventKind = "labeled"
+	EventUnlabeled    EventKind = "unlabeled"
+	EventClosed       EventKind = "closed"
+	EventReopened     EventKind = "reopened"
+	EventMilestoned   EventKind = "milestoned"
+	EventDemilestoned EventKind = "demilestoned"
+	EventRenamed      EventKind = "renamed"
+)
+
+// Event is one unit of issue/PR activity read off GitHub's issue events
+// API: the raw Issue and Actor JSON fragments plus whichever extra
+// field (Label, Assignee or Milestone) that event's Kind carries.
+type Event struct {
+	Kind EventKind
+
 	Issue []byte
 	Actor []byte
+
+	Label     []byte
+	Assignee  []byte
+	Milestone []byte
+}
+
+// LastAccess records the ETag and last seen issue event ID for a single
+// repo, so a poller.Worker can resume from where it left off.
+type LastAccess struct {
+	ETag    string
+	EventID uint64
 }
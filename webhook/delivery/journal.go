@@ -0,0 +1,134 @@
+package delivery
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var pendingBucket = []byte("pending_deliveries")
+
+// record is the durable, journaled representation of one outbound HTTP
+// delivery: enough to retry it verbatim without the caller's help.
+type record struct {
+	URL          string
+	Headers      http.Header
+	Body         []byte
+	Attempt      int
+	FirstAttempt time.Time
+	NextAttempt  time.Time
+}
+
+// Journal durably stores pending deliveries in a bbolt bucket, so a
+// restart picks up exactly where it left off instead of losing whatever
+// was mid-retry.
+type Journal struct {
+	db *bbolt.DB
+}
+
+// NewJournal opens (creating if necessary) the bbolt database at path
+// and ensures the pending-deliveries bucket exists.
+func NewJournal(path string) (*Journal, error) {
+	db, err := bbolt.Open(path, 0644, nil)
+	if err != nil {
+		return nil, fmt.Errorf("delivery: opening journal db %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(pendingBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("delivery: creating bucket: %v", err)
+	}
+
+	return &Journal{db: db}, nil
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// put appends rec as a new entry and returns the ID it was assigned.
+func (j *Journal) put(rec *record) (uint64, error) {
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return 0, fmt.Errorf("delivery: marshalling record: %v", err)
+	}
+
+	var id uint64
+	err = j.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(pendingBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = seq
+		return bucket.Put(itob(id), v)
+	})
+	return id, err
+}
+
+// update overwrites the record stored at id, e.g. after bumping its
+// attempt count and next-attempt time.
+func (j *Journal) update(id uint64, rec *record) error {
+	v, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("delivery: marshalling record: %v", err)
+	}
+
+	return j.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pendingBucket).Put(itob(id), v)
+	})
+}
+
+// delete removes id, either because it delivered successfully or
+// because the manager gave up on it.
+func (j *Journal) delete(id uint64) error {
+	return j.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pendingBucket).Delete(itob(id))
+	})
+}
+
+// due returns every record whose NextAttempt has arrived.
+func (j *Journal) due(now time.Time) (map[uint64]*record, error) {
+	due := make(map[uint64]*record)
+
+	err := j.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pendingBucket).ForEach(func(k, v []byte) error {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if !rec.NextAttempt.After(now) {
+				due[binary.BigEndian.Uint64(k)] = &rec
+			}
+			return nil
+		})
+	})
+
+	return due, err
+}
+
+// count returns the number of deliveries currently pending (in-flight or
+// awaiting their next retry).
+func (j *Journal) count() (int, error) {
+	n := 0
+	err := j.db.View(func(tx *bbolt.Tx) error {
+		n = tx.Bucket(pendingBucket).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// Close releases the underlying bbolt database file.
+func (j *Journal) Close() error {
+	return j.db.Close()
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalPutDueUpdateDelete(t *testing.T) {
+	j, err := NewJournal(filepath.Join(t.TempDir(), "journal.db"))
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	defer j.Close()
+
+	now := time.Now()
+	rec := &record{
+		URL:          "http://example.com/hook",
+		Headers:      http.Header{"X-Test": []string{"1"}},
+		Body:         []byte(`{"a":1}`),
+		FirstAttempt: now,
+		NextAttempt:  now,
+	}
+
+	id, err := j.put(rec)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	due, err := j.due(now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	got, ok := due[id]
+	if !ok {
+		t.Fatalf("due() missing just-put record %d", id)
+	}
+	if got.URL != rec.URL || string(got.Body) != string(rec.Body) {
+		t.Errorf("due() record = %+v, want %+v", got, rec)
+	}
+
+	if n, err := j.count(); err != nil || n != 1 {
+		t.Errorf("count() = %d, %v, want 1, nil", n, err)
+	}
+
+	got.Attempt = 1
+	got.NextAttempt = now.Add(time.Hour)
+	if err := j.update(id, got); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	due, err = j.due(now)
+	if err != nil {
+		t.Fatalf("due after update: %v", err)
+	}
+	if _, ok := due[id]; ok {
+		t.Errorf("due() still lists a record with a future NextAttempt")
+	}
+
+	due, err = j.due(now.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("due after update (far future): %v", err)
+	}
+	if due[id].Attempt != 1 {
+		t.Errorf("due()[id].Attempt = %d, want 1", due[id].Attempt)
+	}
+
+	if err := j.delete(id); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if n, err := j.count(); err != nil || n != 0 {
+		t.Errorf("count() after delete = %d, %v, want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,295 @@
+// Package delivery durably retries outbound webhook HTTP calls. Callers
+// Enqueue a request (URL, headers, body); the Manager journals it and
+// retries with jittered exponential backoff, honoring Retry-After on
+// 429/503, until it succeeds or is given up on.
+package delivery
+
+import (
+	"bytes"
+	"hooksim/config"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBaseDelay      = time.Second
+	defaultMaxDelay       = 5 * time.Minute
+	defaultMaxAttempts    = 30
+	defaultMaxAge         = 24 * time.Hour
+	defaultRequestTimeout = 30 * time.Second
+	defaultMaxConcurrency = 16
+	pollInterval          = time.Second
+)
+
+// Config tunes the Manager's retry policy. Zero values fall back to sane
+// defaults (1s base delay, 5m cap, 30 attempts, 24h max age, 30s per-request
+// timeout, 16 deliveries attempted concurrently).
+type Config struct {
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+	MaxAge      time.Duration
+
+	// RequestTimeout bounds a single delivery HTTP call, so one
+	// downstream that accepts the connection but never responds can't
+	// stall every other queued delivery behind it.
+	RequestTimeout time.Duration
+
+	// MaxConcurrency caps how many due deliveries attemptDue attempts at
+	// once, for the same reason.
+	MaxConcurrency int
+}
+
+func (c Config) withDefaults() Config {
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = defaultBaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = defaultMaxDelay
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.MaxAge <= 0 {
+		c.MaxAge = defaultMaxAge
+	}
+	if c.RequestTimeout <= 0 {
+		c.RequestTimeout = defaultRequestTimeout
+	}
+	if c.MaxConcurrency <= 0 {
+		c.MaxConcurrency = defaultMaxConcurrency
+	}
+	return c
+}
+
+// Stats is a point-in-time snapshot of delivery counters, for
+// observability.
+type Stats struct {
+	Delivered uint64
+	Failed    uint64
+	Pending   int
+}
+
+// Manager journals pending deliveries and retries them in the
+// background. Its Stop/Run/StopReqCh/StopDoneCh shape mirrors
+// poller.Poller, so main can shut every long-running subsystem down the
+// same way.
+type Manager struct {
+	journal *Journal
+	client  *http.Client
+	cfg     Config
+
+	delivered uint64
+	failed    uint64
+
+	StopReqCh  chan bool
+	StopDoneCh chan bool
+}
+
+// NewManager returns a Manager that journals pending deliveries via
+// journal and retries them per cfg.
+func NewManager(journal *Journal, cfg Config) *Manager {
+	cfg = cfg.withDefaults()
+	return &Manager{
+		journal:    journal,
+		client:     &http.Client{Transport: &http.Transport{DisableCompression: true}, Timeout: cfg.RequestTimeout},
+		cfg:        cfg,
+		StopReqCh:  make(chan bool),
+		StopDoneCh: make(chan bool),
+	}
+}
+
+// Enqueue journals a POST of body to url with headers, to be delivered
+// (and retried, if necessary) in the background. It returns once the
+// delivery is durably recorded, not once it has actually succeeded.
+func (m *Manager) Enqueue(url string, headers http.Header, body []byte) error {
+	now := time.Now()
+	_, err := m.journal.put(&record{
+		URL:          url,
+		Headers:      headers,
+		Body:         body,
+		FirstAttempt: now,
+		NextAttempt:  now,
+	})
+	return err
+}
+
+// Stop will stop the delivery manager's retry loop
+func (m *Manager) Stop() {
+	m.StopReqCh <- true
+}
+
+// Run will start retrying due deliveries, this call will block until
+// Stop() is called
+func (m *Manager) Run() {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	defer func() {
+		m.StopDoneCh <- true
+	}()
+
+	for {
+		select {
+		case <-m.StopReqCh:
+			return
+		case <-ticker.C:
+			m.attemptDue()
+		}
+	}
+}
+
+// Stats returns a snapshot of the delivered/failed/pending counters.
+func (m *Manager) Stats() Stats {
+	pending, _ := m.journal.count()
+	return Stats{
+		Delivered: atomic.LoadUint64(&m.delivered),
+		Failed:    atomic.LoadUint64(&m.failed),
+		Pending:   pending,
+	}
+}
+
+// attemptDue attempts every delivery whose NextAttempt has arrived, up to
+// cfg.MaxConcurrency at once, so one slow or hanging downstream can't delay
+// attempts against every other one.
+func (m *Manager) attemptDue() {
+	due, err := m.journal.due(time.Now())
+	if err != nil {
+		slog.Error("error scanning delivery journal", "err", err)
+		return
+	}
+
+	sem := make(chan struct{}, m.cfg.MaxConcurrency)
+	var wg sync.WaitGroup
+
+	for id, rec := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id uint64, rec *record) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.attempt(id, rec)
+		}(id, rec)
+	}
+
+	wg.Wait()
+}
+
+// attempt makes one delivery attempt for rec, then either deletes it
+// (delivered, or given up on) or re-journals it with a later
+// NextAttempt.
+func (m *Manager) attempt(id uint64, rec *record) {
+	rec.Attempt++
+
+	req, err := http.NewRequest("POST", rec.URL, bytes.NewReader(rec.Body))
+	if err != nil {
+		slog.Error("error building delivery request", "delivery_id", id, "url", rec.URL, "attempt", rec.Attempt, "err", err)
+		m.giveUp(id, rec)
+		return
+	}
+	req.Header = rec.Headers
+
+	start := time.Now()
+	resp, err := m.client.Do(req)
+	if config.LogHTTP {
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		slog.Info("outbound delivery", "delivery_id", id, "url", rec.URL, "attempt", rec.Attempt, "status", status, "duration", time.Since(start))
+	}
+
+	if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		resp.Body.Close()
+		if err := m.journal.delete(id); err != nil {
+			slog.Error("error removing delivered entry from journal", "delivery_id", id, "err", err)
+		}
+		atomic.AddUint64(&m.delivered, 1)
+		return
+	}
+
+	var retryAfter time.Duration
+	var haveRetryAfter bool
+	if err == nil {
+		if resp.StatusCode == 429 || resp.StatusCode == 503 {
+			retryAfter, haveRetryAfter = parseRetryAfter(resp)
+		}
+		resp.Body.Close()
+	} else {
+		slog.Error("error delivering", "delivery_id", id, "url", rec.URL, "attempt", rec.Attempt, "err", err)
+	}
+
+	if rec.Attempt >= m.cfg.MaxAttempts || time.Since(rec.FirstAttempt) >= m.cfg.MaxAge {
+		m.giveUp(id, rec)
+		return
+	}
+
+	delay := backoffDelay(rec.Attempt, m.cfg.BaseDelay, m.cfg.MaxDelay)
+	if haveRetryAfter {
+		delay = retryAfter
+	}
+	rec.NextAttempt = time.Now().Add(delay)
+
+	if err := m.journal.update(id, rec); err != nil {
+		slog.Error("error re-journaling delivery", "delivery_id", id, "url", rec.URL, "err", err)
+	}
+}
+
+// giveUp removes a delivery that exhausted its retry budget.
+func (m *Manager) giveUp(id uint64, rec *record) {
+	if err := m.journal.delete(id); err != nil {
+		slog.Error("error removing given-up entry from journal", "delivery_id", id, "err", err)
+	}
+	atomic.AddUint64(&m.failed, 1)
+	slog.Warn("giving up delivery", "delivery_id", id, "url", rec.URL, "attempt", rec.Attempt)
+}
+
+// backoffDelay computes a jittered exponential backoff: base * 2^(attempt-1),
+// capped at max, then scaled by a random factor in [0.5, 1.5).
+func backoffDelay(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	jittered := time.Duration(float64(d) * (0.5 + rand.Float64()))
+	if jittered > max {
+		jittered = max
+	}
+	return jittered
+}
+
+// parseRetryAfter reads the Retry-After header, which GitHub (and the
+// HTTP spec) allow as either a number of seconds or an HTTP-date.
+func parseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,213 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	const base = time.Millisecond
+	const max = time.Hour
+
+	tests := []struct {
+		name    string
+		attempt int
+		wantRaw time.Duration
+	}{
+		{"attempt below 1 clamps to 1", 0, base},
+		{"first attempt", 1, base},
+		{"second attempt doubles", 2, 2 * base},
+		{"fourth attempt", 4, 8 * base},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				d := backoffDelay(tc.attempt, base, max)
+				lo := time.Duration(float64(tc.wantRaw) * 0.5)
+				hi := time.Duration(float64(tc.wantRaw) * 1.5)
+				if d < lo || d > hi {
+					t.Fatalf("backoffDelay(%d) = %v, want within [%v, %v]", tc.attempt, d, lo, hi)
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffDelayCapsAtMax(t *testing.T) {
+	const base = time.Second
+	const max = 5 * time.Minute
+
+	for i := 0; i < 50; i++ {
+		d := backoffDelay(30, base, max)
+		if d <= 0 || d > max {
+			t.Fatalf("backoffDelay(30) = %v, want within (0, %v]", d, max)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantOK  bool
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "seconds",
+			header:  http.Header{"Retry-After": []string{"120"}},
+			wantOK:  true,
+			wantMin: 120 * time.Second,
+			wantMax: 120 * time.Second,
+		},
+		{
+			name:    "http-date in the future",
+			header:  http.Header{"Retry-After": []string{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}},
+			wantOK:  true,
+			wantMin: 59 * time.Minute,
+			wantMax: 61 * time.Minute,
+		},
+		{
+			name:    "http-date in the past clamps to zero",
+			header:  http.Header{"Retry-After": []string{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}},
+			wantOK:  true,
+			wantMin: 0,
+			wantMax: 0,
+		},
+		{
+			name:   "missing header",
+			header: http.Header{},
+			wantOK: false,
+		},
+		{
+			name:   "unparseable header",
+			header: http.Header{"Retry-After": []string{"not-a-number-or-a-date"}},
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &http.Response{Header: tc.header}
+			d, ok := parseRetryAfter(resp)
+			if ok != tc.wantOK {
+				t.Fatalf("parseRetryAfter() ok = %v, want %v", ok, tc.wantOK)
+			}
+			if ok && (d < tc.wantMin || d > tc.wantMax) {
+				t.Fatalf("parseRetryAfter() = %v, want within [%v, %v]", d, tc.wantMin, tc.wantMax)
+			}
+		})
+	}
+}
+
+func newTestManager(t *testing.T, cfg Config) *Manager {
+	t.Helper()
+	j, err := NewJournal(filepath.Join(t.TempDir(), "journal.db"))
+	if err != nil {
+		t.Fatalf("NewJournal: %v", err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return NewManager(j, cfg)
+}
+
+func TestAttemptDelivered(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxAttempts: 3, BaseDelay: time.Millisecond})
+	rec := &record{URL: srv.URL, FirstAttempt: time.Now(), NextAttempt: time.Now()}
+	id, err := m.journal.put(rec)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	m.attempt(id, rec)
+
+	if stats := m.Stats(); stats.Delivered != 1 || stats.Failed != 0 || stats.Pending != 0 {
+		t.Errorf("Stats() = %+v, want {Delivered:1 Failed:0 Pending:0}", stats)
+	}
+}
+
+func TestAttemptRetriesOnFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxAge: time.Hour})
+	now := time.Now()
+	rec := &record{URL: srv.URL, FirstAttempt: now, NextAttempt: now}
+	id, err := m.journal.put(rec)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	m.attempt(id, rec)
+
+	if stats := m.Stats(); stats.Delivered != 0 || stats.Failed != 0 || stats.Pending != 1 {
+		t.Errorf("Stats() = %+v, want {Delivered:0 Failed:0 Pending:1}", stats)
+	}
+
+	due, err := m.journal.due(now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("due: %v", err)
+	}
+	got, ok := due[id]
+	if !ok {
+		t.Fatalf("journal no longer has a record that should still be retried")
+	}
+	if got.Attempt != 1 {
+		t.Errorf("Attempt = %d, want 1", got.Attempt)
+	}
+	if !got.NextAttempt.After(now) {
+		t.Errorf("NextAttempt = %v, want after %v", got.NextAttempt, now)
+	}
+}
+
+func TestAttemptGivesUpAtMaxAttempts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxAge: time.Hour})
+	now := time.Now()
+	rec := &record{URL: srv.URL, FirstAttempt: now, NextAttempt: now, Attempt: 2}
+	id, err := m.journal.put(rec)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	m.attempt(id, rec)
+
+	if stats := m.Stats(); stats.Delivered != 0 || stats.Failed != 1 || stats.Pending != 0 {
+		t.Errorf("Stats() = %+v, want {Delivered:0 Failed:1 Pending:0}", stats)
+	}
+}
+
+func TestAttemptGivesUpAtMaxAge(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	m := newTestManager(t, Config{MaxAttempts: 30, BaseDelay: time.Millisecond, MaxAge: time.Minute})
+	old := time.Now().Add(-time.Hour)
+	rec := &record{URL: srv.URL, FirstAttempt: old, NextAttempt: old}
+	id, err := m.journal.put(rec)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	m.attempt(id, rec)
+
+	if stats := m.Stats(); stats.Delivered != 0 || stats.Failed != 1 || stats.Pending != 0 {
+		t.Errorf("Stats() = %+v, want {Delivered:0 Failed:1 Pending:0}", stats)
+	}
+}
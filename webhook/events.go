@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"fmt"
+	"hooksim/bus"
+	"hooksim/types"
+)
+
+// eventSpec describes how to turn a types.Event of a given Kind into the
+// GitHub issue webhook payload shape: the "action" field GitHub's real
+// webhooks use for it, and which extra field (if any) to splice in
+// alongside "issue"/"repository"/"sender".
+type eventSpec struct {
+	action     string
+	extraField string
+	extra      func(evt *bus.Event) []byte
+}
+
+// EventBuilders is the registry of eventSpecs keyed by types.EventKind,
+// covering every kind worker.supportedEventKinds collects. Subscriber
+// looks a Kind up here to build the payload it hands off to
+// DeliveryManager; a Kind with no entry is one PollRepo should never
+// produce.
+var EventBuilders = map[types.EventKind]eventSpec{
+	types.EventRenamed:      {action: "edited"},
+	types.EventClosed:       {action: "closed"},
+	types.EventReopened:     {action: "reopened"},
+	types.EventAssigned:     {action: "assigned", extraField: "assignee", extra: func(evt *bus.Event) []byte { return evt.Assignee }},
+	types.EventUnassigned:   {action: "unassigned", extraField: "assignee", extra: func(evt *bus.Event) []byte { return evt.Assignee }},
+	types.EventLabeled:      {action: "labeled", extraField: "label", extra: func(evt *bus.Event) []byte { return evt.Label }},
+	types.EventUnlabeled:    {action: "unlabeled", extraField: "label", extra: func(evt *bus.Event) []byte { return evt.Label }},
+	types.EventMilestoned:   {action: "milestoned", extraField: "milestone", extra: func(evt *bus.Event) []byte { return evt.Milestone }},
+	types.EventDemilestoned: {action: "demilestoned", extraField: "milestone", extra: func(evt *bus.Event) []byte { return evt.Milestone }},
+}
+
+// buildPayload renders evt as the JSON body GitHub would send for a real
+// webhook of this kind, with repoContent spliced in as "repository".
+func (spec eventSpec) buildPayload(evt *bus.Event, repoContent string) []byte {
+	var extra string
+	if spec.extraField != "" {
+		v := spec.extra(evt)
+		if len(v) == 0 {
+			v = []byte("null")
+		}
+		extra = fmt.Sprintf(",%q:%s", spec.extraField, string(v))
+	}
+
+	return []byte(fmt.Sprintf("{\"action\":%q,\"issue\":%s%s,\"repository\":%s,\"sender\":%s}",
+		spec.action, string(evt.Issue), extra, repoContent, string(evt.Actor)))
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"encoding/json"
+	"hooksim/bus"
+	"hooksim/types"
+	"testing"
+)
+
+func TestBuildPayloadProducesValidJSONPerKind(t *testing.T) {
+	for kind, spec := range EventBuilders {
+		t.Run(string(kind), func(t *testing.T) {
+			evt := &bus.Event{
+				Owner: "owner",
+				Repo:  "repo",
+				Event: types.Event{
+					Kind:      kind,
+					Issue:     []byte(`{"number":1}`),
+					Actor:     []byte(`{"login":"alice"}`),
+					Label:     []byte(`{"name":"bug"}`),
+					Assignee:  []byte(`{"login":"bob"}`),
+					Milestone: []byte(`{"title":"v1"}`),
+				},
+			}
+
+			payload := spec.buildPayload(evt, `{"full_name":"owner/repo"}`)
+			if !json.Valid(payload) {
+				t.Fatalf("buildPayload(%s) produced invalid JSON: %s", kind, payload)
+			}
+
+			var decoded map[string]json.RawMessage
+			if err := json.Unmarshal(payload, &decoded); err != nil {
+				t.Fatalf("unmarshalling payload: %v", err)
+			}
+
+			var action string
+			json.Unmarshal(decoded["action"], &action)
+			if action != spec.action {
+				t.Errorf("action = %q, want %q", action, spec.action)
+			}
+
+			if spec.extraField != "" {
+				if _, ok := decoded[spec.extraField]; !ok {
+					t.Errorf("payload missing expected extra field %q: %s", spec.extraField, payload)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildPayloadWithEmptyExtraField(t *testing.T) {
+	spec := EventBuilders[types.EventAssigned]
+
+	evt := &bus.Event{
+		Owner: "owner",
+		Repo:  "repo",
+		Event: types.Event{
+			Kind:  types.EventAssigned,
+			Issue: []byte(`{"number":1}`),
+			Actor: []byte(`{"login":"alice"}`),
+			// Assignee deliberately left nil.
+		},
+	}
+
+	payload := spec.buildPayload(evt, `{"full_name":"owner/repo"}`)
+	if !json.Valid(payload) {
+		t.Fatalf("buildPayload with empty extra field produced invalid JSON: %s", payload)
+	}
+
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("unmarshalling payload: %v", err)
+	}
+	if string(decoded["assignee"]) != "null" {
+		t.Errorf(`decoded["assignee"] = %s, want "null"`, decoded["assignee"])
+	}
+}
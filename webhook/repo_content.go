@@ -1,24 +1,14 @@
 package webhook
 
 import (
-	"bytes"
-	"crypto/hmac"
-	"crypto/sha1"
 	"encoding/json"
 	"fmt"
 	"hooksim/config"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
-
-	"github.com/satori/go.uuid"
 )
 
-type IssueActorPair struct {
-	Issue []byte
-	Actor []byte
-}
-
 var (
 	repoFields = [...]string{"id", "name", "full_name", "owner", "private", "html_url", "description", "fork", "url", "forks_url",
 		"keys_url", "collaborators_url", "teams_url", "hooks_url", "issue_events_url", "events_url", "assignees_url",
@@ -33,20 +23,20 @@ var (
 func getRepoContent(owner, repo string, client *http.Client) string {
 	resp, err := client.Get(fmt.Sprintf("%s/repos/%s/%s", config.GithubAPIURL, owner, repo))
 	if err != nil {
-		log.Printf("Error in getting repo content: %v\n", err)
+		slog.Error("error getting repo content", "owner", owner, "repo", repo, "err", err)
 		return "{}"
 	}
 
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("Error in reading repo content: %v\n", err)
+		slog.Error("error reading repo content", "owner", owner, "repo", repo, "err", err)
 		return "{}"
 	}
 	resp.Body.Close()
 
 	var repoMap map[string]json.RawMessage
-	if json.Unmarshal(content, &repoMap) != nil {
-		log.Printf("Error in parsing repo content: %v\n", err)
+	if err := json.Unmarshal(content, &repoMap); err != nil {
+		slog.Error("error parsing repo content", "owner", owner, "repo", repo, "err", err)
 		return "{}"
 	}
 
@@ -61,43 +51,3 @@ func getRepoContent(owner, repo string, client *http.Client) string {
 
 	return output
 }
-
-func TriggerIssueRenamedWebHook(owner, repo string, renamedIssues []IssueActorPair, queryClient *http.Client) {
-	url, secret := getWebHookURL(owner, repo, "issues")
-	if url == "" {
-		return
-	}
-
-	for _, renamedIssue := range renamedIssues {
-		payload := fmt.Sprintf("{\"action\":\"updated\",\"issue\":%s,\"repository\":%s,\"sender\":%s}",
-			string(renamedIssue.Issue),
-			getRepoContent(owner, repo, queryClient),
-			string(renamedIssue.Actor))
-
-		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
-		req, err := http.NewRequest("POST", url, bytes.NewReader([]byte(payload)))
-		if err != nil {
-			log.Printf("Error in creating POST request: %v\n", err)
-		}
-
-		req.Header.Add("User-Agent", "hooksim")
-		req.Header.Add("Content-Type", "application/json")
-		req.Header.Add("Accept", "*/*")
-		req.Header.Add("X-Github-Event", "issues")
-		req.Header.Add("X-Github-Delivery", uuid.NewV4().String())
-		if secret != "" {
-			mac := hmac.New(sha1.New, []byte(secret))
-			mac.Write([]byte(payload))
-			req.Header.Add("X-Hub-Signature", fmt.Sprintf("sha1=%x", mac.Sum(nil)))
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Error in making webhook call: %v\n", err)
-		}
-
-		if resp.Body != nil {
-			resp.Body.Close()
-		}
-	}
-}
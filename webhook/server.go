@@ -1,20 +1,31 @@
 package webhook
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"hooksim/config"
+	"hooksim/types"
+	"hooksim/webhook/delivery"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/tylerb/graceful"
 )
 
+// DeliveryManager journals and retries every outbound HTTP call this
+// package makes, for both the GitHub-event proxy path (handleHook) and
+// the poller's issue-renamed path (see subscriber.go). main wires it up
+// before starting the server.
+var DeliveryManager *delivery.Manager
+
 // getRepoNameAndOwner extract the owner and repo name form the webhook payload sent from github
 func getRepoNameAndOwner(payload []byte) (repoName, owner string, err error) {
 	var event map[string]json.RawMessage
@@ -41,10 +52,55 @@ func getRepoNameAndOwner(payload []byte) (repoName, owner string, err error) {
 	return fields[1], fields[0], err
 }
 
+// accountFor returns the configured Account for owner, if any.
+func accountFor(owner string) (config.Account, bool) {
+	for _, acct := range config.Accounts {
+		if acct.User == owner {
+			return acct, true
+		}
+	}
+	return config.Account{}, false
+}
+
+// verifySignature reports whether header carries a valid HMAC signature
+// of payload under secret, preferring the SHA-256 variant
+// (X-Hub-Signature-256) over the legacy SHA-1 one (X-Hub-Signature) when
+// both are present, same as GitHub itself does.
+func verifySignature(secret string, payload []byte, header http.Header) bool {
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		return checkSignature(sha256.New, secret, payload, sig, "sha256=")
+	}
+	if sig := header.Get("X-Hub-Signature"); sig != "" {
+		return checkSignature(sha1.New, secret, payload, sig, "sha1=")
+	}
+	return false
+}
+
+// checkSignature does a constant-time comparison of signature against
+// prefix+hex(hmac(h, secret, payload)).
+func checkSignature(h func() hash.Hash, secret string, payload []byte, signature, prefix string) bool {
+	if !strings.HasPrefix(signature, prefix) {
+		return false
+	}
+
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	expected := prefix + hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
 // getWebHookURL return the target system's webhook end-point and its secret key
-// specified in the config file.
-func getWebHookURL(owner, repo, event string) []URLSecretPair {
-	var pairs []URLSecretPair
+// specified in the config file. event is either the top-level GitHub
+// category the proxy path (handleHook) forwards under (e.g. "issues"), or
+// one of the individual kinds the poller path (Subscriber.handOff) hands
+// off (e.g. "renamed", "assigned"): a Hook.Events entry of "issues" matches
+// any poller-sourced kind too, same as a real GitHub hook subscribed to the
+// "issues" category would receive all of those actions.
+func getWebHookURL(owner, repo, event string) []types.URLSecretPair {
+	var pairs []types.URLSecretPair
+
+	_, isIssueKind := EventBuilders[types.EventKind(event)]
 
 	for _, acct := range config.Accounts {
 		if acct.User != owner {
@@ -57,13 +113,13 @@ func getWebHookURL(owner, repo, event string) []URLSecretPair {
 			}
 
 			if len(hook.Events) == 1 && hook.Events[0] == "*" {
-				pairs = append(pairs, URLSecretPair{URL: hook.URL, Secret: hook.Secret})
+				pairs = append(pairs, types.URLSecretPair{URL: hook.URL, Secret: hook.Secret})
 				continue
 			}
 
 			for _, e := range hook.Events {
-				if e == event {
-					pairs = append(pairs, URLSecretPair{URL: hook.URL, Secret: hook.Secret})
+				if e == event || (isIssueKind && e == "issues") {
+					pairs = append(pairs, types.URLSecretPair{URL: hook.URL, Secret: hook.Secret})
 					break
 				}
 			}
@@ -75,16 +131,28 @@ func getWebHookURL(owner, repo, event string) []URLSecretPair {
 // handleHook handles the webhook calls sent from github, it will redirect this
 // webhook call to the target system if necessary (depend on the config file)
 func handleHook(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if config.LogHTTP {
+		slog.Info("inbound hook request", "method", r.Method, "url", r.URL.String())
+	}
+
 	payload, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("Error in reading webhook payload: %v\n", err)
+		slog.Error("error reading webhook payload", "err", err)
 		return
 	}
 	r.Body.Close()
 
 	repo, owner, err := getRepoNameAndOwner(payload)
 	if err != nil {
-		log.Printf("Error in unmarshalling webhook payload: %v\n", err)
+		slog.Error("error unmarshalling webhook payload", "err", err)
+		return
+	}
+
+	acct, haveAcct := accountFor(owner)
+	if haveAcct && acct.IncomingSecret != "" && !verifySignature(acct.IncomingSecret, payload, r.Header) {
+		slog.Warn("rejecting webhook call: invalid signature", "owner", owner, "repo", repo)
+		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
@@ -96,48 +164,47 @@ func handleHook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	for _, pair := range pairs {
-		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
-		req, err := http.NewRequest("POST", pair.URL, bytes.NewReader(payload))
-		if err != nil {
-			log.Printf("Error in creating POST request: %v\n", err)
-		}
-
-		req.Header.Add("User-Agent", r.Header.Get("User-Agent"))
-		req.Header.Add("Content-Type", r.Header.Get("Content-Type"))
-		req.Header.Add("Accept", r.Header.Get("Accept"))
-		req.Header.Add("X-Github-Event", event)
-		req.Header.Add("X-Github-Delivery", r.Header.Get("X-Github-Delivery"))
-
-		if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
-			req.Header.Add("X-Hub-Signature", signature)
+		headers := http.Header{}
+		headers.Add("User-Agent", r.Header.Get("User-Agent"))
+		headers.Add("Content-Type", r.Header.Get("Content-Type"))
+		headers.Add("Accept", r.Header.Get("Accept"))
+		headers.Add("X-Github-Event", event)
+		headers.Add("X-Github-Delivery", r.Header.Get("X-Github-Delivery"))
+
+		// Only the incoming signature's own secret-holder can be
+		// trusted to have produced it; forward it as-is when the
+		// downstream shares that secret, otherwise re-sign with the
+		// downstream's own secret so it can still verify the call.
+		if pair.Secret != "" && pair.Secret == acct.IncomingSecret {
+			if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
+				headers.Add("X-Hub-Signature", signature)
+			}
+		} else if pair.Secret != "" {
+			mac := hmac.New(sha1.New, []byte(pair.Secret))
+			mac.Write(payload)
+			headers.Add("X-Hub-Signature", fmt.Sprintf("sha1=%x", mac.Sum(nil)))
 		}
 
-		fmt.Printf("Redirecting Webhook call.\n")
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Error in making webhook call: %v\n", err)
+		slog.Debug("redirecting webhook call", "owner", owner, "repo", repo, "url", pair.URL)
+		if err := DeliveryManager.Enqueue(pair.URL, headers, payload); err != nil {
+			slog.Error("error enqueuing webhook delivery", "owner", owner, "repo", repo, "url", pair.URL, "err", err)
 		}
+	}
 
-		if resp.Body != nil {
-			resp.Body.Close()
-		}
+	if config.LogHTTP {
+		slog.Info("inbound hook request handled", "method", r.Method, "url", r.URL.String(), "duration", time.Since(start))
 	}
 }
 
 // handleHookTester acts as a dummy target system end-point for testing
 func handleHookTester(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("Receive WebHook Call:\n")
-	fmt.Printf("[Header]\n")
-	for k, v := range r.Header {
-		fmt.Printf("\t%v: %s\n", k, v)
-	}
-
 	content, _ := ioutil.ReadAll(r.Body)
-	fmt.Printf("[Body]\n%s\n", string(content))
+	r.Body.Close()
+
 	mac := hmac.New(sha1.New, []byte("test1234"))
 	mac.Write(content)
-	fmt.Printf("chksum:%x\n", mac.Sum(nil))
-	r.Body.Close()
+
+	slog.Info("received webhook call", "headers", r.Header, "body", string(content), "checksum", fmt.Sprintf("%x", mac.Sum(nil)))
 }
 
 // Server return the http server for handling the github webhook call
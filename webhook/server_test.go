@@ -0,0 +1,113 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hooksim/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func sign(h func() hash.Hash, secret string, payload []byte, prefix string) string {
+	mac := hmac.New(h, []byte(secret))
+	mac.Write(payload)
+	return prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret = "s3cr3t"
+	payload := []byte(`{"hello":"world"}`)
+
+	validSHA1 := sign(sha1.New, secret, payload, "sha1=")
+	validSHA256 := sign(sha256.New, secret, payload, "sha256=")
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{
+			name:   "valid sha1 signature accepted",
+			header: http.Header{"X-Hub-Signature": []string{validSHA1}},
+			want:   true,
+		},
+		{
+			name:   "valid sha256 signature accepted",
+			header: http.Header{"X-Hub-Signature-256": []string{validSHA256}},
+			want:   true,
+		},
+		{
+			name:   "sha256 preferred over sha1 when both present",
+			header: http.Header{"X-Hub-Signature": []string{validSHA1}, "X-Hub-Signature-256": []string{validSHA256}},
+			want:   true,
+		},
+		{
+			name:   "invalid sha256 does not fall back to a valid sha1",
+			header: http.Header{"X-Hub-Signature": []string{validSHA1}, "X-Hub-Signature-256": []string{"sha256=deadbeef"}},
+			want:   false,
+		},
+		{
+			name:   "invalid signature rejected",
+			header: http.Header{"X-Hub-Signature": []string{"sha1=deadbeef"}},
+			want:   false,
+		},
+		{
+			name:   "missing signature header rejected",
+			header: http.Header{},
+			want:   false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := verifySignature(secret, payload, tc.header); got != tc.want {
+				t.Errorf("verifySignature() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleHookSignatureVerification(t *testing.T) {
+	payload := []byte(`{"repository":{"full_name":"owner/repo"}}`)
+	const secret = "s3cr3t"
+	validSig := sign(sha1.New, secret, payload, "sha1=")
+
+	origAccounts := config.Accounts
+	defer func() { config.Accounts = origAccounts }()
+
+	tests := []struct {
+		name           string
+		incomingSecret string
+		signature      string
+		wantStatus     int
+	}{
+		{"valid signature accepted", secret, validSig, http.StatusOK},
+		{"invalid signature rejected", secret, "sha1=deadbeef", http.StatusUnauthorized},
+		{"missing signature rejected", secret, "", http.StatusUnauthorized},
+		{"no IncomingSecret configured passes through unverified", "", "", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			config.Accounts = []config.Account{{User: "owner", IncomingSecret: tc.incomingSecret}}
+
+			req := httptest.NewRequest("POST", "/hook", bytes.NewReader(payload))
+			req.Header.Set("X-Github-Event", "issues")
+			if tc.signature != "" {
+				req.Header.Set("X-Hub-Signature", tc.signature)
+			}
+
+			rec := httptest.NewRecorder()
+			handleHook(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
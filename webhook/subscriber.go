@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"fmt"
+	"hooksim/bus"
+	"hooksim/config"
+	"hooksim/types"
+	"hooksim/webhook/delivery"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/satori/go.uuid"
+	"golang.org/x/oauth2"
+)
+
+// repoContentTimeout bounds clientForOwner's calls to GitHub, so a
+// hanging response while building a payload's "repository" field can't
+// stall handOff (and so every other repo's delivery hand-off behind it)
+// indefinitely.
+const repoContentTimeout = 10 * time.Second
+
+// Subscriber pops Events off a bus.PersistentQueue and hands them to
+// DeliveryManager for delivery to the configured downstream webhook
+// URLs. An Event is Ack'd - and so removed from the bus queue - as soon
+// as it has been durably handed off; DeliveryManager's own journal owns
+// retrying the actual HTTP call from there.
+//
+// Its Stop/Run/StopReqCh/StopDoneCh shape mirrors poller.Poller, so main
+// can shut both down the same way.
+type Subscriber struct {
+	Queue           *bus.PersistentQueue
+	DeliveryManager *delivery.Manager
+
+	StopReqCh  chan bool
+	StopDoneCh chan bool
+}
+
+// NewSubscriber returns a Subscriber that delivers events from queue via
+// deliveryManager.
+func NewSubscriber(queue *bus.PersistentQueue, deliveryManager *delivery.Manager) *Subscriber {
+	return &Subscriber{
+		Queue:           queue,
+		DeliveryManager: deliveryManager,
+		StopReqCh:       make(chan bool),
+		StopDoneCh:      make(chan bool),
+	}
+}
+
+// Stop will stop the subscriber task
+func (s *Subscriber) Stop() {
+	s.StopReqCh <- true
+}
+
+// Run will start popping and handing off events, this call will block
+// until Stop() is called
+func (s *Subscriber) Run() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	defer func() {
+		cancel()
+		s.StopDoneCh <- true
+	}()
+
+	go func() {
+		<-s.StopReqCh
+		cancel()
+	}()
+
+	for {
+		qe, err := s.Queue.Pop(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Error("error popping event from queue", "err", err)
+			continue
+		}
+
+		if err := s.handOff(qe.Event); err != nil {
+			slog.Error("error handing off event, will retry", "owner", qe.Event.Owner, "repo", qe.Event.Repo, "kind", qe.Event.Kind, "err", err)
+			continue
+		}
+
+		if err := s.Queue.Ack(qe.ID); err != nil {
+			slog.Error("error acking handed-off event", "id", qe.ID, "err", err)
+		}
+	}
+}
+
+// handOff looks evt.Kind up in EventBuilders, builds the corresponding
+// GitHub issue webhook payload, and enqueues it for every downstream
+// configured for owner/repo whose Hook.Events includes this Kind.
+func (s *Subscriber) handOff(evt *bus.Event) error {
+	spec, ok := EventBuilders[evt.Kind]
+	if !ok {
+		return fmt.Errorf("webhook: no event builder for kind %q", evt.Kind)
+	}
+
+	pairs := getWebHookURL(evt.Owner, evt.Repo, string(evt.Kind))
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	payload := spec.buildPayload(evt, getRepoContent(evt.Owner, evt.Repo, clientForOwner(evt.Owner)))
+
+	for _, pair := range pairs {
+		if err := s.DeliveryManager.Enqueue(pair.URL, signedHeaders(pair, "issues", payload), payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// signedHeaders builds the standard set of headers for an outbound
+// webhook call, signing payload with pair.Secret when one is set.
+func signedHeaders(pair types.URLSecretPair, event string, payload []byte) http.Header {
+	headers := http.Header{}
+	headers.Add("User-Agent", "hooksim")
+	headers.Add("Content-Type", "application/json")
+	headers.Add("Accept", "*/*")
+	headers.Add("X-Github-Event", event)
+	headers.Add("X-Github-Delivery", uuid.NewV4().String())
+	if pair.Secret != "" {
+		mac := hmac.New(sha1.New, []byte(pair.Secret))
+		mac.Write(payload)
+		headers.Add("X-Hub-Signature", fmt.Sprintf("sha1=%x", mac.Sum(nil)))
+	}
+	return headers
+}
+
+// clientForOwner builds an oauth2-authenticated client for owner's
+// GitHub token, for fetching repo content when building payloads.
+func clientForOwner(owner string) *http.Client {
+	for _, acct := range config.Accounts {
+		if acct.User == owner {
+			client := oauth2.NewClient(oauth2.NoContext, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: acct.Token}))
+			client.Timeout = repoContentTimeout
+			return client
+		}
+	}
+	return &http.Client{Timeout: repoContentTimeout}
+}